@@ -1,35 +1,23 @@
 package agent
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"reflect"
-	"strconv"
-	"strings"
+	"sort"
 
 	"aspnet.com/benchmark"
 	"aspnet.com/metrics"
 )
 
-// SubjectMap defines the mapping from a string name to the given testing subject implementation.
-var SubjectMap = map[string]benchmark.Subject{
-	// dummy
-	"dummy": &benchmark.Dummy{},
-	// signalr core
-	"signalr:json:echo":         &benchmark.SignalrCoreJsonEcho{},
-	"signalr:json:broadcast":    &benchmark.SignalrCoreJsonBroadcast{},
-	"signalr:msgpack:echo":      &benchmark.SignalrCoreMsgpackEcho{},
-	"signalr:msgpack:broadcast": &benchmark.SignalrCoreMsgpackBroadcast{},
-	// signalr service
-	"signalr:service:json:echo":              &benchmark.SignalrServiceJsonEcho{},
-	"signalr:service:msgpack:echo":           &benchmark.SignalrServiceMsgpackEcho{},
-	"signalr:service:json:broadcast":         &benchmark.SignalrServiceJsonBroadcast{},
-	"signalr:service:msgpack:broadcast":      &benchmark.SignalrServiceMsgpackBroadcast{},
-	"signalr:service:json:groupbroadcast":    &benchmark.SignalrServiceJsonGroupBroadcast{},
-	"signalr:service:msgpack:groupbroadcast": &benchmark.SignalrServiceMsgpackGroupBroadcast{},
-	// tls
-	"tls:connect": &benchmark.TlsConnect{},
-}
+// SubjectMap defines the mapping from a string name to the given testing
+// subject implementation. It is seeded from benchmark.Registered(), which
+// every built-in subject populates via benchmark.Register from an init()
+// (its own, or the shared one in benchmark/builtin_subjects.go);
+// LoadSubjectPlugin can merge more entries into it at startup.
+var SubjectMap = benchmark.Registered()
 
 // Controller stands for a single agent and exposes management interfaces.
 type Controller struct {
@@ -37,26 +25,65 @@ type Controller struct {
 	Subject   benchmark.Subject
 }
 
-// Invocation represents a command invocation from the master to the agent controller.
-type Invocation struct {
-	Command   string
-	Arguments []string
+// ListSubjects returns the names of every subject this agent supports,
+// built-in or merged in by LoadSubjectPlugin, so the master can discover what
+// a heterogeneous fleet of agents actually supports before dispatching Setup.
+func (c *Controller) ListSubjects(args *struct{}, result *[]string) error {
+	names := make([]string, 0, len(SubjectMap))
+	for name := range SubjectMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	*result = names
+	return nil
 }
 
-func argError(pos int, command string, expected string, given string) error {
-	return fmt.Errorf("The %dth argument for command '%s' is %s, but it cannot be parsed from '%s'", pos, command, expected, given)
+// Invocation represents a command invocation from the master to the agent
+// controller. Arguments are keyed by parameter name and JSON-encoded, so
+// Invoke can decode each one against its CommandDescriptor's declared type
+// (including slices, nested structs, and time.Duration) instead of parsing
+// a flat, positional list of strings.
+type Invocation struct {
+	Command   string
+	Arguments map[string]json.RawMessage
 }
 
 type SetupReply struct {
 	AgentRole string
 }
 
+// tlsConfigurable is implemented by subjects embedding benchmark.WithTLS.
+// Not every subject dials wss://, so Controller type-asserts for it rather
+// than requiring it on benchmark.Subject.
+type tlsConfigurable interface {
+	SetupTLS(cfg *benchmark.TLSConfig) error
+}
+
+// negotiateConfigurable is implemented by subjects embedding
+// benchmark.WithNegotiate. Not every subject performs a SignalR Service
+// negotiate, so Controller type-asserts for it rather than requiring it on
+// benchmark.Subject.
+type negotiateConfigurable interface {
+	SetupNegotiate(cfg *benchmark.NegotiateConfig)
+}
+
 func (c *Controller) Setup(config *benchmark.Config, reply *SetupReply) error {
 	subject, ok := SubjectMap[config.Subject]
 	if !ok {
 		return fmt.Errorf("Cannot find subject: " + config.Subject)
 	}
 	c.Subject = subject
+
+	if tc, ok := subject.(tlsConfigurable); ok {
+		if err := tc.SetupTLS(config.TLSConfig); err != nil {
+			return fmt.Errorf("failed to configure TLS for subject %q: %w", config.Subject, err)
+		}
+	}
+
+	if nc, ok := subject.(negotiateConfigurable); ok {
+		nc.SetupNegotiate(config.NegotiateConfig)
+	}
+
 	if err := c.Subject.Setup(config, subject); err != nil {
 		return err
 	}
@@ -73,6 +100,59 @@ func (c *Controller) CollectCounters(args *struct{}, result *map[string]int64) e
 	return nil
 }
 
+// histogramSource is implemented by subjects embedding benchmark.WithLatencyHistogram.
+// Not every subject records latency histograms, so Controller type-asserts
+// for it rather than requiring it on benchmark.Subject.
+type histogramSource interface {
+	LatencyHistograms() map[string]*benchmark.LatencyHistogram
+}
+
+// CollectLatencyHistogramsReply carries one additively-mergeable HDR
+// histogram snapshot per metric, so the master can merge across agents and
+// report exact global percentiles instead of averaging per-agent ones. Each
+// snapshot is the plain JSON from LatencyHistogram.MarshalSnapshot; it is not
+// compressed.
+type CollectLatencyHistogramsReply struct {
+	Snapshots map[string][]byte
+}
+
+func (c *Controller) CollectLatencyHistograms(args *struct{}, reply *CollectLatencyHistogramsReply) error {
+	if reply.Snapshots == nil {
+		reply.Snapshots = make(map[string][]byte)
+	}
+	hs, ok := c.Subject.(histogramSource)
+	if !ok {
+		return nil
+	}
+	for name, hist := range hs.LatencyHistograms() {
+		data, err := hist.MarshalSnapshot()
+		if err != nil {
+			return fmt.Errorf("failed to marshal latency histogram %q: %w", name, err)
+		}
+		reply.Snapshots[name] = data
+	}
+	return nil
+}
+
+// CollectIntervalPercentilesReply carries per-metric percentiles observed
+// since the previous call, so users can see steady-state latency instead of
+// only lifetime-to-date percentiles.
+type CollectIntervalPercentilesReply struct {
+	Percentiles map[string]benchmark.LatencyPercentiles
+}
+
+func (c *Controller) CollectIntervalPercentiles(args *struct{}, reply *CollectIntervalPercentilesReply) error {
+	reply.Percentiles = make(map[string]benchmark.LatencyPercentiles)
+	hs, ok := c.Subject.(histogramSource)
+	if !ok {
+		return nil
+	}
+	for name, hist := range hs.LatencyHistograms() {
+		reply.Percentiles[name] = hist.ResetInterval()
+	}
+	return nil
+}
+
 type CollectMetricsArgs struct {
 	CollectProcesses []string
 }
@@ -101,71 +181,78 @@ func (c *Controller) CollectMetrics(args *CollectMetricsArgs, result *metrics.Ag
 	return nil
 }
 
-// Invoke calls the method on the agent controller with the name Do<Command>.
+func findCommand(descriptors []benchmark.CommandDescriptor, name string) *benchmark.CommandDescriptor {
+	for i := range descriptors {
+		if descriptors[i].Name == name {
+			return &descriptors[i]
+		}
+	}
+	return nil
+}
+
+// Invoke runs invocation.Command against the current subject's command
+// catalog (see commandDescriptorsFor), decoding each declared parameter out
+// of Invocation.Arguments by its declared Go type.
 func (c *Controller) Invoke(invocation *Invocation, reply *struct{}) error {
 	if invocation == nil {
 		return fmt.Errorf("nil Invocation")
 	}
 
-	subject := reflect.ValueOf(c.Subject)
-	method := subject.MethodByName("Do" + invocation.Command)
-	if !method.IsValid() {
+	command := findCommand(commandDescriptorsFor(c.Subject), invocation.Command)
+	if command == nil {
 		return fmt.Errorf("Command '%s' was not found", invocation.Command)
 	}
 
-	log.Printf("%s(%s)", invocation.Command, strings.Join(invocation.Arguments, ", "))
-
-	argsCount := method.Type().NumIn()
-	if len(invocation.Arguments) != argsCount {
-		return fmt.Errorf("Command '%s' needs %d arguments, %d provided", invocation.Command, argsCount, len(invocation.Arguments))
-	}
-
-	in := make([]reflect.Value, argsCount)
-	for i := 0; i < argsCount; i++ {
-		stringArg := invocation.Arguments[i]
-		t := method.Type().In(i)
-		var arg interface{}
-		var err error
-		switch t.Name() {
-		case "string":
-			arg = stringArg
-		case "bool":
-			arg, err = strconv.ParseBool(stringArg)
-			if err != nil {
-				return argError(i, invocation.Command, t.Name(), stringArg)
+	args := make(map[string]interface{}, len(command.Params))
+	for _, param := range command.Params {
+		raw, ok := invocation.Arguments[param.Name]
+		if !ok {
+			if param.Default == nil {
+				return fmt.Errorf("Command '%s' is missing required argument %q", invocation.Command, param.Name)
 			}
-		case "int":
-			arg, err = strconv.Atoi(stringArg)
-			if err != nil {
-				return argError(i, invocation.Command, t.Name(), stringArg)
-			}
-		case "int32":
-			tmp, err := strconv.ParseInt(stringArg, 10, 32)
-			if err != nil {
-				return argError(i, invocation.Command, t.Name(), stringArg)
-			}
-			arg = int32(tmp)
-		case "float32":
-			tmp, err := strconv.ParseFloat(stringArg, 32)
-			if err != nil {
-				return argError(i, invocation.Command, t.Name(), stringArg)
-			}
-			arg = float32(tmp)
-		case "float64":
-			arg, err = strconv.ParseFloat(stringArg, 64)
-			if err != nil {
-				return argError(i, invocation.Command, t.Name(), stringArg)
-			}
-		// TODO: Support more types
-		default:
-			return fmt.Errorf("The %dth argument type %s for command '%s' is not supported", i, t.Name(), invocation.Command)
+			args[param.Name] = param.Default
+			continue
 		}
-		in[i] = reflect.ValueOf(arg)
+		ptr := reflect.New(param.Type)
+		if err := json.Unmarshal(raw, ptr.Interface()); err != nil {
+			return fmt.Errorf("argument %q for command '%s' is %s, but it cannot be parsed from %q: %w", param.Name, invocation.Command, param.Type, raw, err)
+		}
+		args[param.Name] = ptr.Elem().Interface()
 	}
 
-	response := method.Call(in)
-	if response[0].Interface() == nil {
-		return nil
+	log.Printf("%s(%v)", invocation.Command, args)
+
+	return command.Handler(context.Background(), args)
+}
+
+// ParamDescriptor is the wire-safe mirror of benchmark.ParamSpec: Type is
+// its string name since reflect.Type itself can't cross an RPC boundary.
+type ParamDescriptor struct {
+	Name    string
+	Type    string
+	Default interface{}
+	Doc     string
+}
+
+// CommandCatalogEntry is the wire-safe mirror of benchmark.CommandDescriptor.
+type CommandCatalogEntry struct {
+	Name   string
+	Params []ParamDescriptor
+}
+
+// Describe returns the current subject's full command catalog, so the
+// master (or a UI) can validate arguments and auto-complete commands before
+// ever dispatching Invoke.
+func (c *Controller) Describe(args *struct{}, reply *[]CommandCatalogEntry) error {
+	descriptors := commandDescriptorsFor(c.Subject)
+	catalog := make([]CommandCatalogEntry, 0, len(descriptors))
+	for _, d := range descriptors {
+		params := make([]ParamDescriptor, 0, len(d.Params))
+		for _, p := range d.Params {
+			params = append(params, ParamDescriptor{Name: p.Name, Type: p.Type.String(), Default: p.Default, Doc: p.Doc})
+		}
+		catalog = append(catalog, CommandCatalogEntry{Name: d.Name, Params: params})
 	}
-	return response[0].Interface().(error)
+	*reply = catalog
+	return nil
 }
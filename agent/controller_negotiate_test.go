@@ -0,0 +1,27 @@
+package agent
+
+import (
+	"testing"
+
+	"aspnet.com/benchmark"
+)
+
+// TestControllerSetupWiresNegotiateConfig is a regression test for the bug
+// fixed in a7bb3ba: Controller.Setup never called SetupNegotiate, so
+// Config.NegotiateConfig was dead code for every subject embedding
+// benchmark.WithNegotiate -- MaxRedirects was permanently 0 and
+// Headers/Query/PreferredTransports were unreachable.
+func TestControllerSetupWiresNegotiateConfig(t *testing.T) {
+	subject := &fakeSubject{}
+	withFakeSubject(t, "test:fake-negotiate", subject)
+
+	c := &Controller{}
+	negotiateCfg := &benchmark.NegotiateConfig{MaxRedirects: 2, PreferredTransports: []string{"WebSockets"}}
+	var reply SetupReply
+	if err := c.Setup(&benchmark.Config{Subject: "test:fake-negotiate", NegotiateConfig: negotiateCfg}, &reply); err != nil {
+		t.Fatalf("Setup returned error: %v", err)
+	}
+	if subject.negotiateConfig != negotiateCfg {
+		t.Fatalf("Setup did not call SetupNegotiate with the configured NegotiateConfig")
+	}
+}
@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"testing"
+
+	"aspnet.com/benchmark"
+)
+
+// fakeSubject is a minimal benchmark.Subject stand-in, just enough to
+// exercise Controller.Setup's wiring without any of a real subject's
+// network side effects.
+type fakeSubject struct {
+	setupCalled bool
+	setupConfig *benchmark.Config
+
+	tlsConfig       *benchmark.TLSConfig
+	negotiateConfig *benchmark.NegotiateConfig
+}
+
+func (f *fakeSubject) Setup(config *benchmark.Config, self benchmark.Subject) error {
+	f.setupCalled = true
+	f.setupConfig = config
+	return nil
+}
+
+func (f *fakeSubject) Counters() map[string]int64 { return map[string]int64{} }
+
+func (f *fakeSubject) SetupTLS(cfg *benchmark.TLSConfig) error {
+	f.tlsConfig = cfg
+	return nil
+}
+
+func (f *fakeSubject) SetupNegotiate(cfg *benchmark.NegotiateConfig) {
+	f.negotiateConfig = cfg
+}
+
+// withFakeSubject registers subject under name in SubjectMap for the
+// duration of a test and restores the previous entry (if any) afterward.
+func withFakeSubject(t *testing.T, name string, subject benchmark.Subject) {
+	t.Helper()
+	previous, had := SubjectMap[name]
+	SubjectMap[name] = subject
+	t.Cleanup(func() {
+		if had {
+			SubjectMap[name] = previous
+		} else {
+			delete(SubjectMap, name)
+		}
+	})
+}
+
+// TestControllerSetupWiresTLSConfig is a regression test for the bug fixed
+// in 335b016: Controller.Setup never called SetupTLS, so Config.TLSConfig
+// was dead code for every subject embedding benchmark.WithTLS.
+func TestControllerSetupWiresTLSConfig(t *testing.T) {
+	subject := &fakeSubject{}
+	withFakeSubject(t, "test:fake-tls", subject)
+
+	c := &Controller{}
+	tlsCfg := &benchmark.TLSConfig{ServerName: "example.test"}
+	var reply SetupReply
+	if err := c.Setup(&benchmark.Config{Subject: "test:fake-tls", TLSConfig: tlsCfg}, &reply); err != nil {
+		t.Fatalf("Setup returned error: %v", err)
+	}
+	if subject.tlsConfig != tlsCfg {
+		t.Fatalf("Setup did not call SetupTLS with the configured TLSConfig")
+	}
+	if !subject.setupCalled {
+		t.Fatalf("Setup did not call Subject.Setup")
+	}
+}
+
+// TestControllerSetupWithoutTLSConfig confirms a subject embedding WithTLS
+// still works when the master sends no TLSConfig at all.
+func TestControllerSetupWithoutTLSConfig(t *testing.T) {
+	subject := &fakeSubject{}
+	withFakeSubject(t, "test:fake-tls-nil", subject)
+
+	c := &Controller{}
+	var reply SetupReply
+	if err := c.Setup(&benchmark.Config{Subject: "test:fake-tls-nil"}, &reply); err != nil {
+		t.Fatalf("Setup returned error: %v", err)
+	}
+	if subject.tlsConfig != nil {
+		t.Fatalf("expected SetupTLS to be called with a nil TLSConfig, got %+v", subject.tlsConfig)
+	}
+}
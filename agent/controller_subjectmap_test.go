@@ -0,0 +1,31 @@
+package agent
+
+import "testing"
+
+// TestSubjectMapIncludesAllBuiltins is a regression test for the bug fixed
+// in 4804d27: once SubjectMap was seeded solely from benchmark.Registered(),
+// only tls:handshake (the one built-in with its own self-registering
+// init()) remained -- the other 12 built-ins silently vanished from the
+// agent's advertised capabilities.
+func TestSubjectMapIncludesAllBuiltins(t *testing.T) {
+	want := []string{
+		"dummy",
+		"tls:handshake",
+		"tls:connect",
+		"signalr:json:echo",
+		"signalr:json:broadcast",
+		"signalr:msgpack:echo",
+		"signalr:msgpack:broadcast",
+		"signalr:service:json:echo",
+		"signalr:service:msgpack:echo",
+		"signalr:service:json:broadcast",
+		"signalr:service:msgpack:broadcast",
+		"signalr:service:json:groupbroadcast",
+		"signalr:service:msgpack:groupbroadcast",
+	}
+	for _, name := range want {
+		if _, ok := SubjectMap[name]; !ok {
+			t.Errorf("SubjectMap is missing built-in subject %q", name)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"aspnet.com/benchmark"
+)
+
+// jsonRPCRequest and jsonRPCResponse follow the JSON-RPC 2.0 envelope
+// (https://www.jsonrpc.org/specification) rather than net/rpc's gob wire
+// format, so an agent can be driven from non-Go orchestrators and dashboards.
+type jsonRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type jsonRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type jsonRPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *jsonRPCError   `json:"error,omitempty"`
+}
+
+// jsonRPCNotification is a server-initiated message that carries no ID and
+// expects no response, per the JSON-RPC 2.0 notification shape. The
+// StreamMetrics subscription uses these to push samples.
+type jsonRPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+// JSONRPCServer serves a Controller's command surface as JSON-RPC 2.0, both
+// as a plain HTTP POST endpoint and, for callers that want a persistent
+// connection, over a WebSocket upgraded from the same handler.
+type JSONRPCServer struct {
+	transport ControllerTransport
+	upgrader  websocket.Upgrader
+}
+
+// NewJSONRPCServer returns a JSON-RPC transport in front of c.
+func NewJSONRPCServer(c *Controller) *JSONRPCServer {
+	return &JSONRPCServer{transport: NewControllerTransport(c)}
+}
+
+func (s *JSONRPCServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Upgrade") == "websocket" {
+		s.serveWebSocket(w, r)
+		return
+	}
+	s.serveHTTPPost(w, r)
+}
+
+func (s *JSONRPCServer) serveHTTPPost(w http.ResponseWriter, r *http.Request) {
+	var req jsonRPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.dispatch(&req))
+}
+
+// serveWebSocket handles a persistent JSON-RPC connection. Unlike
+// serveHTTPPost it also understands StreamMetrics/CancelStreamMetrics,
+// which need a connection to push notifications on outside of the
+// request/response cycle; a plain HTTP POST has no way to do that.
+func (s *JSONRPCServer) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var writeMu sync.Mutex
+	writeJSON := func(v interface{}) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteJSON(v)
+	}
+
+	var stream *metricsStream
+	defer func() {
+		if stream != nil {
+			stream.stop()
+		}
+	}()
+
+	for {
+		var req jsonRPCRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+
+		switch req.Method {
+		case "StreamMetrics":
+			if stream != nil {
+				stream.stop()
+			}
+			var params streamMetricsParams
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				if writeJSON(&jsonRPCResponse{JSONRPC: "2.0", ID: req.ID, Error: &jsonRPCError{Code: -32602, Message: err.Error()}}) != nil {
+					return
+				}
+				continue
+			}
+			stream = s.startMetricsStream(params, writeJSON)
+			if writeJSON(&jsonRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: "streaming"}) != nil {
+				return
+			}
+		case "CancelStreamMetrics":
+			if stream != nil {
+				stream.stop()
+				stream = nil
+			}
+			if writeJSON(&jsonRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: "cancelled"}) != nil {
+				return
+			}
+		default:
+			if writeJSON(s.dispatch(&req)) != nil {
+				return
+			}
+		}
+	}
+}
+
+// streamMetricsParams configures a StreamMetrics subscription: which
+// processes to report resource usage for, and how often to push a sample.
+type streamMetricsParams struct {
+	Processes  []string
+	IntervalMs int64
+}
+
+// metricsStream is a running StreamMetrics subscription on one WebSocket
+// connection; stop cancels its background ticker.
+type metricsStream struct {
+	cancel context.CancelFunc
+}
+
+func (m *metricsStream) stop() {
+	m.cancel()
+}
+
+// startMetricsStream pushes CollectMetrics results as "StreamMetrics"
+// notifications every params.IntervalMs (default 1s) until stopped, so a
+// jsonrpc-transport master can watch metrics in real time instead of
+// polling CollectMetrics on an interval of its own.
+func (s *JSONRPCServer) startMetricsStream(params streamMetricsParams, writeJSON func(interface{}) error) *metricsStream {
+	interval := time.Duration(params.IntervalMs) * time.Millisecond
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				result, err := s.transport.CollectMetrics(params.Processes)
+				if err != nil {
+					writeJSON(&jsonRPCNotification{JSONRPC: "2.0", Method: "StreamMetrics:error", Params: err.Error()})
+					continue
+				}
+				writeJSON(&jsonRPCNotification{JSONRPC: "2.0", Method: "StreamMetrics", Params: result})
+			}
+		}
+	}()
+	return &metricsStream{cancel: cancel}
+}
+
+func (s *JSONRPCServer) dispatch(req *jsonRPCRequest) *jsonRPCResponse {
+	resp := &jsonRPCResponse{JSONRPC: "2.0", ID: req.ID}
+	result, err := s.call(req.Method, req.Params)
+	if err != nil {
+		resp.Error = &jsonRPCError{Code: -32000, Message: err.Error()}
+		return resp
+	}
+	resp.Result = result
+	return resp
+}
+
+func (s *JSONRPCServer) call(method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "Setup":
+		var config benchmark.Config
+		if err := json.Unmarshal(params, &config); err != nil {
+			return nil, err
+		}
+		return s.transport.Setup(&config)
+	case "Invoke":
+		var invocation Invocation
+		if err := json.Unmarshal(params, &invocation); err != nil {
+			return nil, err
+		}
+		return nil, s.transport.Invoke(&invocation)
+	case "CollectCounters":
+		return s.transport.CollectCounters()
+	case "CollectMetrics":
+		var args CollectMetricsArgs
+		if err := json.Unmarshal(params, &args); err != nil {
+			return nil, err
+		}
+		return s.transport.CollectMetrics(args.CollectProcesses)
+	case "ListSubjects":
+		return s.transport.ListSubjects()
+	case "Describe":
+		return s.transport.Describe()
+	case "CollectLatencyHistograms":
+		return s.transport.CollectLatencyHistograms()
+	case "CollectIntervalPercentiles":
+		return s.transport.CollectIntervalPercentiles()
+	default:
+		return nil, fmt.Errorf("unknown method %q", method)
+	}
+}
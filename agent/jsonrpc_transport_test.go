@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"aspnet.com/benchmark"
+	"aspnet.com/metrics"
+)
+
+// fakeTransport is a minimal ControllerTransport stub so JSONRPCServer can
+// be exercised without a real *Controller/*benchmark.Subject behind it.
+type fakeTransport struct {
+	collectMetricsCalls int
+}
+
+func (f *fakeTransport) Setup(config *benchmark.Config) (*SetupReply, error) {
+	return &SetupReply{}, nil
+}
+func (f *fakeTransport) Invoke(invocation *Invocation) error        { return nil }
+func (f *fakeTransport) CollectCounters() (map[string]int64, error) { return map[string]int64{}, nil }
+func (f *fakeTransport) CollectMetrics(processes []string) (*metrics.AgentMetrics, error) {
+	f.collectMetricsCalls++
+	return &metrics.AgentMetrics{}, nil
+}
+func (f *fakeTransport) ListSubjects() ([]string, error)          { return nil, nil }
+func (f *fakeTransport) Describe() ([]CommandCatalogEntry, error) { return nil, nil }
+func (f *fakeTransport) CollectLatencyHistograms() (*CollectLatencyHistogramsReply, error) {
+	return &CollectLatencyHistogramsReply{}, nil
+}
+func (f *fakeTransport) CollectIntervalPercentiles() (*CollectIntervalPercentilesReply, error) {
+	return &CollectIntervalPercentilesReply{}, nil
+}
+
+// TestJSONRPCServerStreamMetrics verifies that a StreamMetrics subscription
+// pushes repeated notifications without the client re-requesting, and that
+// CancelStreamMetrics stops them -- the behavior that replaces gRPC's
+// StreamMetrics rpc on the jsonrpc transport.
+func TestJSONRPCServerStreamMetrics(t *testing.T) {
+	transport := &fakeTransport{}
+	server := &JSONRPCServer{transport: transport}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(httpServer.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("failed to dial jsonrpc websocket: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteJSON(&jsonRPCRequest{
+		JSONRPC: "2.0",
+		ID:      []byte(`1`),
+		Method:  "StreamMetrics",
+		Params:  []byte(`{"IntervalMs": 10}`),
+	}); err != nil {
+		t.Fatalf("failed to send StreamMetrics request: %v", err)
+	}
+
+	var ack jsonRPCResponse
+	if err := conn.ReadJSON(&ack); err != nil {
+		t.Fatalf("failed to read StreamMetrics ack: %v", err)
+	}
+	if ack.Error != nil {
+		t.Fatalf("StreamMetrics returned an error: %+v", ack.Error)
+	}
+
+	for i := 0; i < 2; i++ {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		var note jsonRPCNotification
+		if err := conn.ReadJSON(&note); err != nil {
+			t.Fatalf("expected a StreamMetrics notification, got error: %v", err)
+		}
+		if note.Method != "StreamMetrics" {
+			t.Fatalf("expected method StreamMetrics, got %q", note.Method)
+		}
+	}
+
+	if err := conn.WriteJSON(&jsonRPCRequest{
+		JSONRPC: "2.0",
+		ID:      []byte(`2`),
+		Method:  "CancelStreamMetrics",
+	}); err != nil {
+		t.Fatalf("failed to send CancelStreamMetrics request: %v", err)
+	}
+	var cancelAck jsonRPCResponse
+	if err := conn.ReadJSON(&cancelAck); err != nil {
+		t.Fatalf("failed to read CancelStreamMetrics ack: %v", err)
+	}
+
+	// Drain any notification already in flight when the cancel landed,
+	// then assert the stream really stopped.
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	var trailing jsonRPCNotification
+	_ = conn.ReadJSON(&trailing)
+
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	var stray jsonRPCNotification
+	if err := conn.ReadJSON(&stray); err == nil {
+		t.Fatalf("expected no more notifications after CancelStreamMetrics, got %+v", stray)
+	}
+}
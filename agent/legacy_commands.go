@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"aspnet.com/benchmark"
+)
+
+// commandDescriptorsFor returns subject's command catalog: its own
+// Commands() if it implements benchmark.CommandSource, or a reflection-based
+// adapter over its Do<Command> methods otherwise, so subjects that haven't
+// migrated keep working unchanged.
+func commandDescriptorsFor(subject benchmark.Subject) []benchmark.CommandDescriptor {
+	if src, ok := subject.(benchmark.CommandSource); ok {
+		return src.Commands()
+	}
+	return legacyCommands(subject)
+}
+
+// legacyCommands builds one CommandDescriptor per Do<Name> method on
+// subject via reflection. Reflection can't recover a method's parameter
+// names, so params are named arg0, arg1, ... in declaration order.
+func legacyCommands(subject benchmark.Subject) []benchmark.CommandDescriptor {
+	v := reflect.ValueOf(subject)
+	t := v.Type()
+
+	var descriptors []benchmark.CommandDescriptor
+	for i := 0; i < t.NumMethod(); i++ {
+		method := t.Method(i)
+		if !strings.HasPrefix(method.Name, "Do") || len(method.Name) == len("Do") {
+			continue
+		}
+
+		methodValue := v.Method(i)
+		methodType := methodValue.Type()
+		params := make([]benchmark.ParamSpec, methodType.NumIn())
+		for p := range params {
+			params[p] = benchmark.ParamSpec{
+				Name: fmt.Sprintf("arg%d", p),
+				Type: methodType.In(p),
+			}
+		}
+
+		descriptors = append(descriptors, benchmark.CommandDescriptor{
+			Name:    strings.TrimPrefix(method.Name, "Do"),
+			Params:  params,
+			Handler: legacyHandler(methodValue, params),
+		})
+	}
+	return descriptors
+}
+
+// legacyHandler adapts a reflect.Value method call to the
+// func(context.Context, map[string]interface{}) error shape every
+// CommandDescriptor.Handler uses.
+func legacyHandler(method reflect.Value, params []benchmark.ParamSpec) func(context.Context, map[string]interface{}) error {
+	return func(_ context.Context, args map[string]interface{}) error {
+		in := make([]reflect.Value, len(params))
+		for i, param := range params {
+			arg, ok := args[param.Name]
+			if !ok {
+				return fmt.Errorf("missing argument %q", param.Name)
+			}
+			rv := reflect.ValueOf(arg)
+			if !rv.IsValid() || !rv.Type().AssignableTo(param.Type) {
+				if rv.IsValid() && rv.Type().ConvertibleTo(param.Type) {
+					rv = rv.Convert(param.Type)
+				} else {
+					return fmt.Errorf("argument %q: expected %s, got %T", param.Name, param.Type, arg)
+				}
+			}
+			in[i] = rv
+		}
+
+		response := method.Call(in)
+		if response[0].Interface() == nil {
+			return nil
+		}
+		return response[0].Interface().(error)
+	}
+}
@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"aspnet.com/benchmark"
+)
+
+// legacyFakeSubject exposes one Do<Command> method so legacyCommands/
+// legacyHandler (the reflection-based adapter commandDescriptorsFor falls
+// back to for subjects that don't implement benchmark.CommandSource) can be
+// exercised without a real subject.
+type legacyFakeSubject struct {
+	pinged string
+}
+
+func (s *legacyFakeSubject) Setup(config *benchmark.Config, self benchmark.Subject) error { return nil }
+func (s *legacyFakeSubject) Counters() map[string]int64                                   { return map[string]int64{} }
+
+func (s *legacyFakeSubject) DoPing(name string) error {
+	if name == "" {
+		return fmt.Errorf("name is required")
+	}
+	s.pinged = name
+	return nil
+}
+
+func TestLegacyCommandsDescribesDoMethods(t *testing.T) {
+	subject := &legacyFakeSubject{}
+	descriptors := legacyCommands(subject)
+
+	cmd := findCommand(descriptors, "Ping")
+	if cmd == nil {
+		t.Fatalf("expected a Ping command built from DoPing, got %v", descriptors)
+	}
+	if len(cmd.Params) != 1 || cmd.Params[0].Name != "arg0" {
+		t.Fatalf("expected one param named arg0, got %+v", cmd.Params)
+	}
+}
+
+func TestLegacyHandlerInvokesDoMethod(t *testing.T) {
+	subject := &legacyFakeSubject{}
+	descriptors := legacyCommands(subject)
+	cmd := findCommand(descriptors, "Ping")
+	if cmd == nil {
+		t.Fatalf("expected a Ping command")
+	}
+
+	if err := cmd.Handler(context.Background(), map[string]interface{}{"arg0": "hello"}); err != nil {
+		t.Fatalf("Handler returned error: %v", err)
+	}
+	if subject.pinged != "hello" {
+		t.Fatalf("DoPing was not invoked with the decoded argument, got %q", subject.pinged)
+	}
+
+	if err := cmd.Handler(context.Background(), map[string]interface{}{}); err == nil {
+		t.Fatalf("expected an error for a missing required argument")
+	}
+}
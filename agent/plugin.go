@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"fmt"
+	"plugin"
+
+	"aspnet.com/benchmark"
+)
+
+// LoadSubjectPlugin opens the Go plugin at path (e.g. path/to/x.so) and
+// merges the subjects it exports into subjects (normally agent.SubjectMap).
+// This package defines no command-line flag that calls it. A plugin
+// exports either:
+//
+//   - a `Subjects map[string]benchmark.Subject` symbol, for a fixed set of
+//     pre-constructed instances, or
+//   - a `Register func(func(name string, s benchmark.Subject))` hook: the
+//     plugin is handed a callback and calls it once per subject it wants
+//     to register, mirroring how built-in subjects call benchmark.Register
+//     from their own init().
+//
+// It is an error for a plugin to export neither, or to register a name
+// that collides with a subject already present in subjects.
+func LoadSubjectPlugin(path string, subjects map[string]benchmark.Subject) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open subject plugin %q: %w", path, err)
+	}
+
+	add := func(name string, s benchmark.Subject) error {
+		if _, exists := subjects[name]; exists {
+			return fmt.Errorf("subject plugin %q: subject %q collides with an already-registered subject", path, name)
+		}
+		subjects[name] = s
+		return nil
+	}
+
+	if sym, lookupErr := p.Lookup("Subjects"); lookupErr == nil {
+		exported, ok := sym.(*map[string]benchmark.Subject)
+		if !ok {
+			return fmt.Errorf("subject plugin %q: Subjects symbol has the wrong type", path)
+		}
+		for name, s := range *exported {
+			if err := add(name, s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	sym, err := p.Lookup("Register")
+	if err != nil {
+		return fmt.Errorf("subject plugin %q exports neither Subjects nor Register", path)
+	}
+	register, ok := sym.(func(func(name string, s benchmark.Subject)))
+	if !ok {
+		return fmt.Errorf("subject plugin %q: Register symbol has the wrong signature", path)
+	}
+
+	var addErr error
+	register(func(name string, s benchmark.Subject) {
+		if addErr == nil {
+			addErr = add(name, s)
+		}
+	})
+	return addErr
+}
@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/rpc"
+)
+
+// TransportKind selects which wire protocol the agent serves its Controller
+// over. Serve treats the zero value ("") as RPCTransport, so callers that
+// leave it unset keep the net/rpc behavior.
+//
+// gRPC is out of scope for now: proto/controller.proto is kept as a
+// reference spec for a future Controller mirror, but this repo has no
+// committed generated pb sources and nowhere to run `protoc` as part of the
+// build, so there is deliberately no GRPCTransportKind. The "stop polling
+// for metrics" need that spec's StreamMetrics rpc was meant to address is
+// served instead by the jsonrpc transport's StreamMetrics/CancelStreamMetrics
+// WebSocket methods (see JSONRPCServer.serveWebSocket), which need no codegen.
+type TransportKind string
+
+const (
+	// RPCTransport serves the Controller over Go's stdlib net/rpc, as before.
+	RPCTransport TransportKind = "rpc"
+	// JSONRPCTransportKind serves it as JSON-RPC 2.0 over HTTP/WebSocket.
+	JSONRPCTransportKind TransportKind = "jsonrpc"
+)
+
+// Serve starts listening for master connections to c using the transport
+// named by kind, blocking until the listener errors out.
+func Serve(c *Controller, kind TransportKind, addr string) error {
+	switch kind {
+	case "", RPCTransport:
+		if err := rpc.Register(c); err != nil {
+			return err
+		}
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return err
+			}
+			go rpc.ServeConn(conn)
+		}
+	case JSONRPCTransportKind:
+		return http.ListenAndServe(addr, NewJSONRPCServer(c))
+	default:
+		return fmt.Errorf("unknown transport %q", kind)
+	}
+}
@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"aspnet.com/benchmark"
+	"aspnet.com/metrics"
+)
+
+// ControllerTransport is the transport-neutral command surface a Controller
+// exposes to the master. JSONRPCServer adapts to this interface instead of
+// re-implementing dispatch, so adding a transport never touches Controller
+// itself. The stdlib net/rpc server (see Serve) registers *Controller
+// directly and does not go through this interface.
+type ControllerTransport interface {
+	Setup(config *benchmark.Config) (*SetupReply, error)
+	Invoke(invocation *Invocation) error
+	CollectCounters() (map[string]int64, error)
+	CollectMetrics(processes []string) (*metrics.AgentMetrics, error)
+	ListSubjects() ([]string, error)
+	Describe() ([]CommandCatalogEntry, error)
+	CollectLatencyHistograms() (*CollectLatencyHistogramsReply, error)
+	CollectIntervalPercentiles() (*CollectIntervalPercentilesReply, error)
+}
+
+// controllerTransport adapts a *Controller's net/rpc-shaped methods
+// (args/reply pointer pairs) to the plain request/response ControllerTransport.
+type controllerTransport struct {
+	c *Controller
+}
+
+// NewControllerTransport wraps c so its command surface can be served over
+// any transport in this package, in addition to net/rpc.
+func NewControllerTransport(c *Controller) ControllerTransport {
+	return &controllerTransport{c: c}
+}
+
+func (t *controllerTransport) Setup(config *benchmark.Config) (*SetupReply, error) {
+	var reply SetupReply
+	if err := t.c.Setup(config, &reply); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
+func (t *controllerTransport) Invoke(invocation *Invocation) error {
+	var reply struct{}
+	return t.c.Invoke(invocation, &reply)
+}
+
+func (t *controllerTransport) CollectCounters() (map[string]int64, error) {
+	result := make(map[string]int64)
+	if err := t.c.CollectCounters(&struct{}{}, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (t *controllerTransport) CollectMetrics(processes []string) (*metrics.AgentMetrics, error) {
+	var result metrics.AgentMetrics
+	args := &CollectMetricsArgs{CollectProcesses: processes}
+	if err := t.c.CollectMetrics(args, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (t *controllerTransport) ListSubjects() ([]string, error) {
+	var result []string
+	if err := t.c.ListSubjects(&struct{}{}, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (t *controllerTransport) Describe() ([]CommandCatalogEntry, error) {
+	var result []CommandCatalogEntry
+	if err := t.c.Describe(&struct{}{}, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (t *controllerTransport) CollectLatencyHistograms() (*CollectLatencyHistogramsReply, error) {
+	var reply CollectLatencyHistogramsReply
+	if err := t.c.CollectLatencyHistograms(&struct{}{}, &reply); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
+func (t *controllerTransport) CollectIntervalPercentiles() (*CollectIntervalPercentilesReply, error) {
+	var reply CollectIntervalPercentilesReply
+	if err := t.c.CollectIntervalPercentiles(&struct{}{}, &reply); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
@@ -0,0 +1,36 @@
+package benchmark
+
+import (
+	"context"
+	"reflect"
+)
+
+// ParamSpec describes one parameter of a CommandDescriptor: the name it is
+// looked up under in Invocation.Arguments, its Go type (used to decode the
+// JSON-encoded argument precisely, including time.Duration, slices, and
+// nested structs), a default applied when the argument is omitted, and a
+// short doc string surfaced through Controller.Describe.
+type ParamSpec struct {
+	Name    string
+	Type    reflect.Type
+	Default interface{}
+	Doc     string
+}
+
+// CommandDescriptor is one command a subject exposes to the master: a name,
+// its parameter schema, and the handler that runs it. This replaces
+// reflect.MethodByName("Do"+Command) dispatch with an explicit catalog the
+// master can validate and auto-complete against before ever dispatching.
+type CommandDescriptor struct {
+	Name    string
+	Params  []ParamSpec
+	Handler func(ctx context.Context, args map[string]interface{}) error
+}
+
+// CommandSource is implemented by subjects that declare their command
+// surface as CommandDescriptors. Subjects that don't implement it keep
+// working via the legacy Do<Command> reflection adapter in the agent
+// package.
+type CommandSource interface {
+	Commands() []CommandDescriptor
+}
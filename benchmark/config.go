@@ -0,0 +1,17 @@
+package benchmark
+
+// Config is what the master sends an agent to tell it which subject to run
+// and how to configure it.
+type Config struct {
+	Subject string
+
+	// TLSConfig configures wss:// and https:// connections made by
+	// subjects that embed WithTLS. Nil means plain ws:///http://.
+	TLSConfig *TLSConfig
+
+	// NegotiateConfig customizes the negotiateVersion=1 handshake
+	// subjects that embed WithNegotiate perform before dialing the
+	// websocket. Nil means no extra headers/query, no redirects, and no
+	// transport preference.
+	NegotiateConfig *NegotiateConfig
+}
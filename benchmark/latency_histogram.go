@@ -0,0 +1,177 @@
+package benchmark
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+const (
+	// latencyHistogramMinMillis and latencyHistogramMaxMillis bound the
+	// trackable range at 1ms..60s. Samples arrive as whole milliseconds
+	// from ProcessJsonLatency/ProcessMsgPackLatency (both truncate via
+	// integer division before calling RecordLatency), so there's no
+	// sub-ms precision to preserve by tracking a finer unit.
+	latencyHistogramMinMillis = 1
+	latencyHistogramMaxMillis = 60 * 1000
+	latencyHistogramSigFigs   = 3
+)
+
+// LatencyPercentiles is the small summary callers actually want to look at;
+// the full histogram is only needed for merging and HGRM export.
+type LatencyPercentiles struct {
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	P999  float64 `json:"p99_9"`
+	Max   float64 `json:"max"`
+	Count int64   `json:"count"`
+}
+
+// LatencyHistogram accumulates per-message latency samples into an
+// HDR histogram instead of a single rolling sample, so both per-agent and,
+// once merged on the master, global percentiles are exact rather than
+// averaged. It tracks two histograms: a lifetime one for the whole run, and
+// an interval one that ResetInterval() periodically drains, so callers can
+// see steady-state percentiles rather than only lifetime-to-date ones.
+type LatencyHistogram struct {
+	mu       sync.Mutex
+	lifetime *hdrhistogram.Histogram
+	interval *hdrhistogram.Histogram
+}
+
+// NewLatencyHistogram returns an empty histogram tracking 1ms..60s at 3
+// significant digits, matching the resolution SignalR round-trip latencies
+// need without blowing up memory.
+func NewLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{
+		lifetime: hdrhistogram.New(latencyHistogramMinMillis, latencyHistogramMaxMillis, latencyHistogramSigFigs),
+		interval: hdrhistogram.New(latencyHistogramMinMillis, latencyHistogramMaxMillis, latencyHistogramSigFigs),
+	}
+}
+
+// RecordMillis records a latency sample given in milliseconds, the unit
+// ProcessJsonLatency/ProcessMsgPackLatency already compute.
+func (h *LatencyHistogram) RecordMillis(ms int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lifetime.RecordValue(ms)
+	h.interval.RecordValue(ms)
+}
+
+func summarize(hist *hdrhistogram.Histogram) LatencyPercentiles {
+	return LatencyPercentiles{
+		P50:   float64(hist.ValueAtQuantile(50)),
+		P90:   float64(hist.ValueAtQuantile(90)),
+		P99:   float64(hist.ValueAtQuantile(99)),
+		P999:  float64(hist.ValueAtQuantile(99.9)),
+		Max:   float64(hist.Max()),
+		Count: hist.TotalCount(),
+	}
+}
+
+// Percentiles returns the lifetime p50/p90/p99/p99.9/max latencies in
+// milliseconds.
+func (h *LatencyHistogram) Percentiles() LatencyPercentiles {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return summarize(h.lifetime)
+}
+
+// ResetInterval returns the percentiles observed since the previous call (or
+// since creation) and clears the interval histogram, so repeated calls give
+// steady-state rather than lifetime-only stats.
+func (h *LatencyHistogram) ResetInterval() LatencyPercentiles {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	snapshot := summarize(h.interval)
+	h.interval.Reset()
+	return snapshot
+}
+
+// MarshalSnapshot JSON-encodes the lifetime histogram's hdrhistogram.Snapshot
+// (bucket counts plus the range/precision they were recorded at), which is
+// additively mergeable: MergeSnapshots can combine any number of these
+// without losing precision, unlike averaging percentiles.
+func (h *LatencyHistogram) MarshalSnapshot() ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return json.Marshal(h.lifetime.Export())
+}
+
+// MergeSnapshots decodes and additively merges per-agent histogram
+// snapshots into one, so the master can report exact global percentiles
+// instead of averaging per-agent ones.
+func MergeSnapshots(snapshots [][]byte) (*hdrhistogram.Histogram, error) {
+	merged := hdrhistogram.New(latencyHistogramMinMillis, latencyHistogramMaxMillis, latencyHistogramSigFigs)
+	for i, data := range snapshots {
+		snap := &hdrhistogram.Snapshot{}
+		if err := json.Unmarshal(data, snap); err != nil {
+			return nil, fmt.Errorf("failed to decode histogram snapshot %d: %w", i, err)
+		}
+		merged.Merge(hdrhistogram.Import(snap))
+	}
+	return merged, nil
+}
+
+// WriteHGRM writes hist as a percentile table under the HGRM column header,
+// for offline analysis. It writes one row for each of the fixed percentiles
+// 50, 75, 90, 95, 99, 99.9, 99.99, 99.999 and 100. It does not write the
+// full HGRM output: the TotalCount column holds the histogram's total count
+// on every row rather than the cumulative count at that percentile, and no
+// mean/stddev footer is written.
+func WriteHGRM(w io.Writer, hist *hdrhistogram.Histogram) error {
+	if _, err := fmt.Fprintln(w, "       Value     Percentile TotalCount 1/(1-Percentile)"); err != nil {
+		return err
+	}
+	for _, q := range []float64{50, 75, 90, 95, 99, 99.9, 99.99, 99.999, 100} {
+		value := hist.ValueAtQuantile(q)
+		inverse := "inf"
+		if q < 100 {
+			inverse = fmt.Sprintf("%.2f", 1/(1-q/100))
+		}
+		if _, err := fmt.Fprintf(w, "%12.3f %14.6f %10d %14s\n",
+			float64(value), q/100, hist.TotalCount(), inverse); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithLatencyHistogram gives a subject a set of named HDR latency
+// histograms, one per metric, created lazily on first observation.
+type WithLatencyHistogram struct {
+	mu         sync.Mutex
+	histograms map[string]*LatencyHistogram
+}
+
+// RecordLatency records ms into the named histogram, creating it on first use.
+func (w *WithLatencyHistogram) RecordLatency(metric string, ms int64) {
+	w.mu.Lock()
+	if w.histograms == nil {
+		w.histograms = make(map[string]*LatencyHistogram)
+	}
+	h, ok := w.histograms[metric]
+	if !ok {
+		h = NewLatencyHistogram()
+		w.histograms[metric] = h
+	}
+	w.mu.Unlock()
+	h.RecordMillis(ms)
+}
+
+// LatencyHistograms returns the subject's histograms keyed by metric name,
+// for the agent's CollectLatencyHistograms/CollectIntervalPercentiles to
+// snapshot and ship to the master.
+func (w *WithLatencyHistogram) LatencyHistograms() map[string]*LatencyHistogram {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	result := make(map[string]*LatencyHistogram, len(w.histograms))
+	for k, v := range w.histograms {
+		result[k] = v
+	}
+	return result
+}
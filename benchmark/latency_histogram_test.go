@@ -0,0 +1,88 @@
+package benchmark
+
+import "testing"
+
+func TestLatencyHistogramPercentiles(t *testing.T) {
+	h := NewLatencyHistogram()
+	for _, ms := range []int64{10, 20, 30, 40, 100} {
+		h.RecordMillis(ms)
+	}
+
+	got := h.Percentiles()
+	if got.Count != 5 {
+		t.Fatalf("Count = %d, want 5", got.Count)
+	}
+	if got.Max != 100 {
+		t.Fatalf("Max = %v, want 100", got.Max)
+	}
+	if got.P50 < 20 || got.P50 > 40 {
+		t.Fatalf("P50 = %v, want roughly 20-40", got.P50)
+	}
+}
+
+func TestLatencyHistogramResetInterval(t *testing.T) {
+	h := NewLatencyHistogram()
+	h.RecordMillis(5)
+	h.RecordMillis(15)
+
+	first := h.ResetInterval()
+	if first.Count != 2 {
+		t.Fatalf("first interval Count = %d, want 2", first.Count)
+	}
+
+	second := h.ResetInterval()
+	if second.Count != 0 {
+		t.Fatalf("second interval Count = %d, want 0 after reset", second.Count)
+	}
+
+	// Lifetime percentiles are unaffected by ResetInterval.
+	if lifetime := h.Percentiles(); lifetime.Count != 2 {
+		t.Fatalf("lifetime Count = %d, want 2", lifetime.Count)
+	}
+}
+
+func TestMergeSnapshots(t *testing.T) {
+	a := NewLatencyHistogram()
+	a.RecordMillis(10)
+	a.RecordMillis(20)
+	snapA, err := a.MarshalSnapshot()
+	if err != nil {
+		t.Fatalf("MarshalSnapshot a: %v", err)
+	}
+
+	b := NewLatencyHistogram()
+	b.RecordMillis(30)
+	b.RecordMillis(40)
+	snapB, err := b.MarshalSnapshot()
+	if err != nil {
+		t.Fatalf("MarshalSnapshot b: %v", err)
+	}
+
+	merged, err := MergeSnapshots([][]byte{snapA, snapB})
+	if err != nil {
+		t.Fatalf("MergeSnapshots: %v", err)
+	}
+	if got := merged.TotalCount(); got != 4 {
+		t.Fatalf("merged TotalCount = %d, want 4", got)
+	}
+	if got := merged.Max(); got != 40 {
+		t.Fatalf("merged Max = %d, want 40", got)
+	}
+}
+
+func TestRecordLatencyCreatesHistogramsLazily(t *testing.T) {
+	var w WithLatencyHistogram
+	if got := w.LatencyHistograms(); len(got) != 0 {
+		t.Fatalf("expected no histograms before any RecordLatency call, got %d", len(got))
+	}
+
+	w.RecordLatency("message:latency", 42)
+	histograms := w.LatencyHistograms()
+	hist, ok := histograms["message:latency"]
+	if !ok {
+		t.Fatalf("expected a histogram for %q", "message:latency")
+	}
+	if got := hist.Percentiles().Count; got != 1 {
+		t.Fatalf("Count = %d, want 1", got)
+	}
+}
@@ -0,0 +1,172 @@
+package benchmark
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// NegotiateConfig customizes the negotiateVersion=1 handshake
+// SignalrServiceBaseConnect performs before dialing the websocket.
+type NegotiateConfig struct {
+	// Headers are added to every negotiate request, e.g. for auth that
+	// isn't carried as a query string parameter.
+	Headers map[string]string
+	// Query is appended to the negotiate URL alongside negotiateVersion=1.
+	Query map[string]string
+	// MaxRedirects bounds how many RedirectUrl/AccessToken hops are
+	// followed before giving up. 0 means negotiate must succeed on the
+	// first hop.
+	MaxRedirects int
+	// PreferredTransports ranks transport names (e.g. "WebSockets",
+	// "ServerSentEvents", "LongPolling") in the order they should be
+	// tried against the server's availableTransports. The first
+	// preference present in availableTransports wins; an empty list
+	// means take the server's first entry.
+	PreferredTransports []string
+}
+
+// AvailableTransport is one entry of a negotiateVersion=1 response's
+// availableTransports array.
+type AvailableTransport struct {
+	Transport       string   `json:"transport"`
+	TransferFormats []string `json:"transferFormats"`
+}
+
+// selectTransport picks a transport name from the handshake's
+// availableTransports according to prefs: the first preference present in
+// availableTransports wins. With no preferences, or if none of them are
+// present, it falls back to the server's first offering. With no menu at
+// all (availableTransports empty), it returns "" and the caller falls back
+// to whatever ServiceUrl already implies.
+func selectTransport(available []AvailableTransport, prefs []string) string {
+	if len(available) == 0 {
+		return ""
+	}
+	if len(prefs) == 0 {
+		return available[0].Transport
+	}
+	present := make(map[string]bool, len(available))
+	for _, t := range available {
+		present[t.Transport] = true
+	}
+	for _, want := range prefs {
+		if present[want] {
+			return want
+		}
+	}
+	return available[0].Transport
+}
+
+// negotiateV1 performs the negotiateVersion=1 handshake against base,
+// following up to cfg.MaxRedirects RedirectUrl hops, and records
+// negotiate:redirect / negotiate:success / negotiate:error:<status>
+// counters so the negotiate path cost can be seen separately from the
+// websocket dial itself.
+func (s *SignalrCoreCommon) negotiateV1(base string, cfg *NegotiateConfig) (*SignalrServiceHandshake, error) {
+	if cfg == nil {
+		cfg = &NegotiateConfig{}
+	}
+
+	endpoint := base
+	bearer := ""
+	for hop := 0; ; hop++ {
+		handshake, err := s.negotiateOnce(endpoint, bearer, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		if handshake.RedirectUrl == "" {
+			s.counter.Stat("negotiate:success", 1)
+			return handshake, nil
+		}
+
+		if hop >= cfg.MaxRedirects {
+			return nil, fmt.Errorf("negotiate: exceeded %d redirect hop(s) without a final handshake", cfg.MaxRedirects)
+		}
+		s.counter.Stat("negotiate:redirect", 1)
+		endpoint = handshake.RedirectUrl
+		bearer = handshake.JwtBearer
+	}
+}
+
+func (s *SignalrCoreCommon) negotiateOnce(base string, bearer string, cfg *NegotiateConfig) (*SignalrServiceHandshake, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("negotiate: invalid URL %q: %w", base, err)
+	}
+	if !hasPathSuffix(u.Path, "/negotiate") {
+		u.Path = joinPath(u.Path, "negotiate")
+	}
+
+	q := u.Query()
+	q.Set("negotiateVersion", "1")
+	for k, v := range cfg.Query {
+		q.Set(k, v)
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	resp, err := s.HttpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("negotiate: request to %q failed: %w", u.String(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		s.counter.Stat(fmt.Sprintf("negotiate:error:%d", resp.StatusCode), 1)
+		return nil, fmt.Errorf("negotiate: %q returned status %d", u.String(), resp.StatusCode)
+	}
+
+	var handshake SignalrServiceHandshake
+	if err := json.NewDecoder(resp.Body).Decode(&handshake); err != nil {
+		s.counter.Stat("negotiate:error:decode", 1)
+		return nil, fmt.Errorf("negotiate: failed to decode response from %q: %w", u.String(), err)
+	}
+	return &handshake, nil
+}
+
+// WithNegotiate gives a subject configurable negotiateVersion=1 behavior:
+// extra headers/query string, redirect following, and transport preference.
+type WithNegotiate struct {
+	negotiateConfig *NegotiateConfig
+}
+
+// SetupNegotiate installs cfg for subsequent negotiateV1 calls. A nil cfg
+// means negotiate with no extra headers/query, no redirects, and no
+// transport preference (take the server's first offering).
+func (w *WithNegotiate) SetupNegotiate(cfg *NegotiateConfig) {
+	w.negotiateConfig = cfg
+}
+
+// NegotiateConfigOrDefault returns the configured NegotiateConfig, or an
+// empty one if SetupNegotiate was never called.
+func (w *WithNegotiate) NegotiateConfigOrDefault() *NegotiateConfig {
+	if w.negotiateConfig == nil {
+		return &NegotiateConfig{}
+	}
+	return w.negotiateConfig
+}
+
+func hasPathSuffix(path, suffix string) bool {
+	n := len(suffix)
+	return len(path) >= n && path[len(path)-n:] == suffix
+}
+
+func joinPath(base, segment string) string {
+	if len(base) > 0 && base[len(base)-1] == '/' {
+		return base + segment
+	}
+	return base + "/" + segment
+}
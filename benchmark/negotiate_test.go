@@ -0,0 +1,73 @@
+package benchmark
+
+import "testing"
+
+func TestSelectTransport(t *testing.T) {
+	available := []AvailableTransport{
+		{Transport: "WebSockets"},
+		{Transport: "ServerSentEvents"},
+		{Transport: "LongPolling"},
+	}
+
+	cases := []struct {
+		name  string
+		avail []AvailableTransport
+		prefs []string
+		want  string
+	}{
+		{"no preferences falls back to server's first", available, nil, "WebSockets"},
+		{"preferred transport present wins", available, []string{"LongPolling", "WebSockets"}, "LongPolling"},
+		{"none of the preferences are present falls back", available, []string{"WebTransport"}, "WebSockets"},
+		{"empty menu returns empty", nil, []string{"WebSockets"}, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := selectTransport(c.avail, c.prefs); got != c.want {
+				t.Fatalf("selectTransport(%v, %v) = %q, want %q", c.avail, c.prefs, got, c.want)
+			}
+		})
+	}
+}
+
+func TestHasPathSuffix(t *testing.T) {
+	cases := []struct {
+		path, suffix string
+		want         bool
+	}{
+		{"/hub/negotiate", "/negotiate", true},
+		{"/negotiate", "/negotiate", true},
+		{"/hub", "/negotiate", false},
+		{"", "/negotiate", false},
+	}
+	for _, c := range cases {
+		if got := hasPathSuffix(c.path, c.suffix); got != c.want {
+			t.Fatalf("hasPathSuffix(%q, %q) = %v, want %v", c.path, c.suffix, got, c.want)
+		}
+	}
+}
+
+func TestJoinPath(t *testing.T) {
+	cases := []struct{ base, segment, want string }{
+		{"/hub", "negotiate", "/hub/negotiate"},
+		{"/hub/", "negotiate", "/hub/negotiate"},
+		{"", "negotiate", "/negotiate"},
+	}
+	for _, c := range cases {
+		if got := joinPath(c.base, c.segment); got != c.want {
+			t.Fatalf("joinPath(%q, %q) = %q, want %q", c.base, c.segment, got, c.want)
+		}
+	}
+}
+
+func TestNegotiateConfigOrDefault(t *testing.T) {
+	var w WithNegotiate
+	if got := w.NegotiateConfigOrDefault(); got == nil || got.MaxRedirects != 0 || len(got.PreferredTransports) != 0 {
+		t.Fatalf("expected an empty NegotiateConfig before SetupNegotiate, got %+v", got)
+	}
+
+	cfg := &NegotiateConfig{MaxRedirects: 3, PreferredTransports: []string{"WebSockets"}}
+	w.SetupNegotiate(cfg)
+	if got := w.NegotiateConfigOrDefault(); got != cfg {
+		t.Fatalf("expected NegotiateConfigOrDefault to return the configured NegotiateConfig")
+	}
+}
@@ -0,0 +1,36 @@
+package benchmark
+
+import "fmt"
+
+// registry backs Register/Registered. It is package-level state rather than
+// a struct so built-in subjects can self-register from an init() in their
+// own file without anyone threading a registry instance through.
+var registry = make(map[string]func() Subject)
+
+// Register adds a named subject factory to the built-in registry. Built-in
+// subjects call this from an init(), either in their own file, e.g.:
+//
+//	func init() {
+//		Register("tls:handshake", func() Subject { return &TlsHandshake{} })
+//	}
+//
+// or, for the older subjects, from the shared init() in builtin_subjects.go.
+// This lets agent.SubjectMap be assembled from Registered() instead of a
+// hardcoded map literal, so adding a new scenario no longer means touching
+// the agent package at all.
+func Register(name string, factory func() Subject) {
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("benchmark: subject %q already registered", name))
+	}
+	registry[name] = factory
+}
+
+// Registered constructs one fresh instance per registered subject, keyed by
+// name, ready to seed agent.SubjectMap.
+func Registered() map[string]Subject {
+	result := make(map[string]Subject, len(registry))
+	for name, factory := range registry {
+		result[name] = factory()
+	}
+	return result
+}
@@ -5,24 +5,33 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
 	"regexp"
 	"strconv"
 	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/teris-io/shortid"
 	"github.com/vmihailenco/msgpack"
 )
 
+// SignalrServiceHandshake is the negotiateVersion=1 response shape, a
+// superset of the legacy {url, accessToken} Azure SignalR Service
+// handshake: it may additionally carry a connectionId, a transport menu, or
+// a redirect to another negotiate endpoint (in which case accessToken is
+// the bearer to use against RedirectUrl, not ServiceUrl).
 type SignalrServiceHandshake struct {
-	ServiceUrl string `json:"url"`
-	JwtBearer  string `json:"accessToken"`
+	ServiceUrl          string               `json:"url"`
+	JwtBearer           string               `json:"accessToken"`
+	ConnectionId        string               `json:"connectionId"`
+	AvailableTransports []AvailableTransport `json:"availableTransports"`
+	RedirectUrl         string               `json:"redirectUrl"`
 }
 
 type SignalrCoreCommon struct {
 	WithCounter
 	WithSessions
+	WithTLS
+	WithLatencyHistogram
+	WithNegotiate
 }
 
 func (s *SignalrCoreCommon) SignalrCoreBaseConnect(protocol string) (session *Session, err error) {
@@ -40,12 +49,21 @@ func (s *SignalrCoreCommon) SignalrCoreBaseConnect(protocol string) (session *Se
 	}
 
 	s.counter.Stat("connection:inprogress", 1)
-	wsURL := "ws://" + s.host
-	c, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	wsURL := s.WsScheme() + s.host
+
+	dialStart := time.Now()
+	c, _, err := s.Dialer().Dial(wsURL, nil)
 	if err != nil {
 		s.LogError("connection:error", id, "Failed to connect to websocket", err)
 		return nil, err
 	}
+	if s.Enabled() {
+		// Distinct from "tls:connect", which measures the bare TCP
+		// handshake; this is the full dial including the TLS handshake
+		// (and, when SessionResumption is on, ticket reuse).
+		s.counter.Stat("tls:handshake", 1)
+		s.counter.Stat("tls:handshake:ms", time.Since(dialStart).Milliseconds())
+	}
 
 	session = NewSession(id, s.received, s.counter, c)
 	if session != nil {
@@ -85,36 +103,46 @@ func (s *SignalrCoreCommon) SignalrServiceBaseConnect(protocol string) (session
 		return
 	}
 
-	negotiateResponse, err := http.Get("http://" + s.host + "/negotiate")
+	handshake, err := s.negotiateV1(s.HttpScheme()+s.host, s.NegotiateConfigOrDefault())
 	if err != nil {
 		s.LogError("connection:error", id, "Failed to negotiate with the server", err)
 		return
 	}
-	defer negotiateResponse.Body.Close()
-
-	decoder := json.NewDecoder(negotiateResponse.Body)
-	var handshake SignalrServiceHandshake
-	err = decoder.Decode(&handshake)
-	if err != nil {
-		s.LogError("connection:error", id, "Failed to decode service URL and jwtBearer", err)
-		return
-	}
 
 	var httpPrefix = regexp.MustCompile("^https?://")
-	var ws string
+	ws := s.WsScheme()
 	if s.useWss {
 		ws = "wss://"
-	} else {
-		ws = "ws://"
 	}
 	baseURL := httpPrefix.ReplaceAllString(handshake.ServiceUrl, ws)
 	wsURL := baseURL + "&access_token=" + handshake.JwtBearer
+	if handshake.ConnectionId != "" {
+		wsURL += "&id=" + handshake.ConnectionId
+	}
+	// This subject only ever dials the websocket transport ServiceUrl
+	// already targets, so a resolved preference for anything else can't
+	// be honored. Rather than silently dialing the websocket anyway,
+	// reject it so a misconfigured PreferredTransports fails loudly
+	// instead of quietly measuring a different transport than requested.
+	transport := selectTransport(handshake.AvailableTransports, s.NegotiateConfigOrDefault().PreferredTransports)
+	if transport != "" && transport != "WebSockets" {
+		err = fmt.Errorf("negotiate: server selected transport %q, but only WebSockets is implemented", transport)
+		return
+	}
+	if transport != "" {
+		s.counter.Stat("negotiate:transport:"+transport, 1)
+	}
 
-	c, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	dialStart := time.Now()
+	c, _, err := s.Dialer().Dial(wsURL, nil)
 	if err != nil {
 		s.LogError("connection:error", id, "Failed to connect to websocket", err)
 		return
 	}
+	if s.Enabled() || s.useWss {
+		s.counter.Stat("tls:handshake", 1)
+		s.counter.Stat("tls:handshake:ms", time.Since(dialStart).Milliseconds())
+	}
 	session = NewSession(id, s.received, s.counter, c)
 	if session != nil {
 		s.counter.Stat("connection:inprogress", -1)
@@ -194,7 +222,9 @@ func (s *SignalrCoreCommon) ProcessJsonLatency(target string) {
 					s.LogError("message:decode_error", msgReceived.ClientID, "Failed to decode start timestamp", err)
 					continue
 				}
-				s.LogLatency((time.Now().UnixNano() - sendStart) / 1000000)
+				latencyMs := (time.Now().UnixNano() - sendStart) / 1000000
+				s.LogLatency(latencyMs)
+				s.RecordLatency("message:latency", latencyMs)
 			}
 		}
 	}
@@ -220,7 +250,9 @@ func (s *SignalrCoreCommon) ProcessMsgPackLatency(target string) {
 				s.LogError("message:decode_error", msgReceived.ClientID, "Failed to decode start timestamp", err)
 				continue
 			}
-			s.LogLatency((time.Now().UnixNano() - sendStart) / 1000000)
+			latencyMs := (time.Now().UnixNano() - sendStart) / 1000000
+			s.LogLatency(latencyMs)
+			s.RecordLatency("message:latency", latencyMs)
 		}
 	}
 }
@@ -0,0 +1,281 @@
+package benchmark
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TLSConfig describes how a subject should dial wss:// and https:// endpoints.
+// It is embedded in Config and threaded through Setup into a per-subject
+// *websocket.Dialer so every connection a subject opens honors the same
+// trust settings.
+type TLSConfig struct {
+	// CACertPath, when set, is used instead of the system root pool to
+	// verify the server certificate.
+	CACertPath string
+	// ClientCertPath and ClientKeyPath enable mTLS by presenting a client
+	// certificate during the handshake. Both must be set together.
+	ClientCertPath string
+	ClientKeyPath  string
+	// InsecureSkipVerify disables all certificate validation. Only meant
+	// for testing against self-signed endpoints.
+	InsecureSkipVerify bool
+	// ServerName overrides the SNI name sent in the ClientHello, useful
+	// when dialing an IP or a load balancer in front of multiple hosts.
+	ServerName string
+	// PinnedSPKISHA256 is a list of base64 or hex encoded SHA-256 digests
+	// of the server certificate's SPKI. When non-empty, the leaf
+	// certificate's SPKI must match one of these pins or the handshake
+	// is rejected, regardless of chain validation.
+	PinnedSPKISHA256 []string
+	// MinVersion and MaxVersion are TLS version strings ("1.0".."1.3").
+	// Empty means use the crypto/tls default for that bound.
+	MinVersion string
+	MaxVersion string
+	// CipherSuites is an allow-list of cipher suite names as recognized
+	// by tls.CipherSuiteName. Empty means allow the Go default set.
+	CipherSuites []string
+	// SessionResumption enables TLS session ticket caching so repeated
+	// dials to the same host can resume instead of doing a full handshake.
+	SessionResumption bool
+}
+
+var tlsVersionByName = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var cipherSuiteByName = func() map[string]uint16 {
+	m := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		m[suite.Name] = suite.ID
+	}
+	return m
+}()
+
+// spkiSHA256 computes the SHA-256 digest of a certificate's SubjectPublicKeyInfo,
+// i.e. the same value HPKP/curl --pinnedpubkey pin against.
+func spkiSHA256(cert *x509.Certificate) [32]byte {
+	return sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+}
+
+// decodeSPKIPin decodes a PinnedSPKISHA256 entry, accepting either hex or
+// base64 (standard or URL-safe, padded or not) as the doc comment on
+// TLSConfig.PinnedSPKISHA256 promises, since pin lists are commonly copied
+// straight out of tools like openssl or curl --pinnedpubkey that use
+// whichever encoding is locally conventional.
+func decodeSPKIPin(pin string) ([sha256.Size]byte, bool) {
+	var digest [sha256.Size]byte
+	if b, err := hex.DecodeString(pin); err == nil && len(b) == sha256.Size {
+		copy(digest[:], b)
+		return digest, true
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(pin); err == nil && len(b) == sha256.Size {
+			copy(digest[:], b)
+			return digest, true
+		}
+	}
+	return digest, false
+}
+
+// verifyPins returns a VerifyPeerCertificate callback that additionally
+// requires the leaf certificate's SPKI to match one of the given pins.
+// It does no chain verification itself: buildTLSClientConfig forces
+// crypto/tls's own verification off when pins are set and runs its chain
+// check before this one, unless InsecureSkipVerify is set, in which case
+// this is the only check.
+func verifyPins(pins []string) func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("tls: no peer certificate presented")
+		}
+		leaf, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return fmt.Errorf("tls: failed to parse peer certificate: %w", err)
+		}
+		digest := spkiSHA256(leaf)
+		for _, pin := range pins {
+			decoded, ok := decodeSPKIPin(pin)
+			if ok && decoded == digest {
+				return nil
+			}
+		}
+		return fmt.Errorf("tls: peer certificate SPKI pin mismatch")
+	}
+}
+
+// buildTLSClientConfig turns a TLSConfig into a *tls.Config suitable for
+// websocket.Dialer.TLSClientConfig.
+func buildTLSClientConfig(cfg *TLSConfig) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		ServerName:         cfg.ServerName,
+		ClientSessionCache: nil,
+	}
+
+	if cfg.SessionResumption {
+		tlsCfg.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+
+	if cfg.CACertPath != "" {
+		caCert, err := ioutil.ReadFile(cfg.CACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("tls: failed to read CA bundle %q: %w", cfg.CACertPath, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("tls: no certificates found in CA bundle %q", cfg.CACertPath)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.ClientCertPath != "" || cfg.ClientKeyPath != "" {
+		if cfg.ClientCertPath == "" || cfg.ClientKeyPath == "" {
+			return nil, fmt.Errorf("tls: ClientCertPath and ClientKeyPath must both be set for mTLS")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertPath, cfg.ClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("tls: failed to load client key pair: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.MinVersion != "" {
+		v, ok := tlsVersionByName[cfg.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("tls: unknown MinVersion %q", cfg.MinVersion)
+		}
+		tlsCfg.MinVersion = v
+	}
+	if cfg.MaxVersion != "" {
+		v, ok := tlsVersionByName[cfg.MaxVersion]
+		if !ok {
+			return nil, fmt.Errorf("tls: unknown MaxVersion %q", cfg.MaxVersion)
+		}
+		tlsCfg.MaxVersion = v
+	}
+
+	if len(cfg.CipherSuites) > 0 {
+		suites := make([]uint16, 0, len(cfg.CipherSuites))
+		for _, name := range cfg.CipherSuites {
+			id, ok := cipherSuiteByName[name]
+			if !ok {
+				return nil, fmt.Errorf("tls: unknown cipher suite %q", name)
+			}
+			suites = append(suites, id)
+		}
+		tlsCfg.CipherSuites = suites
+	}
+
+	if len(cfg.PinnedSPKISHA256) > 0 {
+		// Pinning replaces the default verifier, so we must still run
+		// chain validation ourselves unless the caller explicitly opted
+		// out of it via InsecureSkipVerify.
+		tlsCfg.InsecureSkipVerify = true
+		pins := cfg.PinnedSPKISHA256
+		wantChainVerify := !cfg.InsecureSkipVerify
+		tlsCfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if wantChainVerify {
+				leaf, err := x509.ParseCertificate(rawCerts[0])
+				if err != nil {
+					return fmt.Errorf("tls: failed to parse peer certificate: %w", err)
+				}
+				intermediates := x509.NewCertPool()
+				for _, raw := range rawCerts[1:] {
+					cert, err := x509.ParseCertificate(raw)
+					if err != nil {
+						return fmt.Errorf("tls: failed to parse intermediate certificate: %w", err)
+					}
+					intermediates.AddCert(cert)
+				}
+				opts := x509.VerifyOptions{Roots: tlsCfg.RootCAs, Intermediates: intermediates, DNSName: tlsCfg.ServerName}
+				if _, err := leaf.Verify(opts); err != nil {
+					return fmt.Errorf("tls: chain verification failed: %w", err)
+				}
+			}
+			return verifyPins(pins)(rawCerts, nil)
+		}
+	}
+
+	return tlsCfg, nil
+}
+
+// WithTLS gives a subject a TLS-aware *websocket.Dialer built from a
+// TLSConfig, and tracks whether wss:// should be used in place of ws://.
+type WithTLS struct {
+	tlsConfig *TLSConfig
+	dialer    *websocket.Dialer
+}
+
+// SetupTLS builds the subject's dialer from cfg. A nil cfg leaves the
+// subject dialing plain ws:// with websocket.DefaultDialer.
+func (w *WithTLS) SetupTLS(cfg *TLSConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	tlsCfg, err := buildTLSClientConfig(cfg)
+	if err != nil {
+		return err
+	}
+	w.tlsConfig = cfg
+	w.dialer = &websocket.Dialer{
+		TLSClientConfig:  tlsCfg,
+		HandshakeTimeout: 45 * time.Second,
+	}
+	return nil
+}
+
+// Enabled reports whether TLS was configured for this subject.
+func (w *WithTLS) Enabled() bool {
+	return w.tlsConfig != nil
+}
+
+// Dialer returns the subject's *websocket.Dialer, falling back to
+// websocket.DefaultDialer when TLS was not configured.
+func (w *WithTLS) Dialer() *websocket.Dialer {
+	if w.dialer == nil {
+		return websocket.DefaultDialer
+	}
+	return w.dialer
+}
+
+// WsScheme returns "wss://" when TLS is configured, "ws://" otherwise.
+func (w *WithTLS) WsScheme() string {
+	if w.Enabled() {
+		return "wss://"
+	}
+	return "ws://"
+}
+
+// HttpScheme returns "https://" when TLS is configured, "http://" otherwise.
+func (w *WithTLS) HttpScheme() string {
+	if w.Enabled() {
+		return "https://"
+	}
+	return "http://"
+}
+
+// HttpClient returns an *http.Client that shares this subject's TLS
+// settings, for use against https:// negotiate endpoints.
+func (w *WithTLS) HttpClient() *http.Client {
+	if w.dialer == nil {
+		return http.DefaultClient
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: w.dialer.TLSClientConfig,
+		},
+	}
+}
@@ -0,0 +1,181 @@
+package benchmark
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// testCertChain is a throwaway root -> intermediate -> leaf chain for
+// exercising pin verification and chain validation without a real CA.
+type testCertChain struct {
+	leafDER         []byte
+	intermediateDER []byte
+	leaf            *x509.Certificate
+	roots           *x509.CertPool
+}
+
+func generateTestCertChain(t *testing.T, serverName string) *testCertChain {
+	t.Helper()
+
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate root key: %v", err)
+	}
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test root"},
+		NotBefore:             time.Unix(0, 0),
+		NotAfter:              time.Unix(0, 0).Add(100 * 365 * 24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatalf("failed to create root certificate: %v", err)
+	}
+	rootCert, err := x509.ParseCertificate(rootDER)
+	if err != nil {
+		t.Fatalf("failed to parse root certificate: %v", err)
+	}
+
+	intermediateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate intermediate key: %v", err)
+	}
+	intermediateTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "test intermediate"},
+		NotBefore:             time.Unix(0, 0),
+		NotAfter:              time.Unix(0, 0).Add(100 * 365 * 24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	intermediateDER, err := x509.CreateCertificate(rand.Reader, intermediateTemplate, rootCert, &intermediateKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatalf("failed to create intermediate certificate: %v", err)
+	}
+	intermediateCert, err := x509.ParseCertificate(intermediateDER)
+	if err != nil {
+		t.Fatalf("failed to parse intermediate certificate: %v", err)
+	}
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate leaf key: %v", err)
+	}
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: serverName},
+		DNSNames:     []string{serverName},
+		NotBefore:    time.Unix(0, 0),
+		NotAfter:     time.Unix(0, 0).Add(100 * 365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, intermediateCert, &leafKey.PublicKey, intermediateKey)
+	if err != nil {
+		t.Fatalf("failed to create leaf certificate: %v", err)
+	}
+	leafCert, err := x509.ParseCertificate(leafDER)
+	if err != nil {
+		t.Fatalf("failed to parse leaf certificate: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(rootCert)
+
+	return &testCertChain{
+		leafDER:         leafDER,
+		intermediateDER: intermediateDER,
+		leaf:            leafCert,
+		roots:           roots,
+	}
+}
+
+func TestDecodeSPKIPin(t *testing.T) {
+	digest := sha256.Sum256([]byte("some SPKI bytes"))
+
+	cases := []struct {
+		name string
+		pin  string
+	}{
+		{"hex", hex.EncodeToString(digest[:])},
+		{"base64-std", base64.StdEncoding.EncodeToString(digest[:])},
+		{"base64-std-no-padding", base64.RawStdEncoding.EncodeToString(digest[:])},
+		{"base64-url", base64.URLEncoding.EncodeToString(digest[:])},
+		{"base64-url-no-padding", base64.RawURLEncoding.EncodeToString(digest[:])},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := decodeSPKIPin(c.pin)
+			if !ok {
+				t.Fatalf("decodeSPKIPin(%q) failed to decode", c.pin)
+			}
+			if got != digest {
+				t.Fatalf("decodeSPKIPin(%q) = %x, want %x", c.pin, got, digest)
+			}
+		})
+	}
+
+	if _, ok := decodeSPKIPin("not a pin"); ok {
+		t.Fatalf("decodeSPKIPin should reject garbage input")
+	}
+}
+
+func TestVerifyPinsMatchesEitherEncoding(t *testing.T) {
+	chain := generateTestCertChain(t, "pinned.test")
+	digest := spkiSHA256(chain.leaf)
+
+	verify := verifyPins([]string{base64.StdEncoding.EncodeToString(digest[:])})
+	if err := verify([][]byte{chain.leafDER}, nil); err != nil {
+		t.Fatalf("verifyPins rejected a base64-encoded pin that matches: %v", err)
+	}
+
+	verifyHex := verifyPins([]string{hex.EncodeToString(digest[:])})
+	if err := verifyHex([][]byte{chain.leafDER}, nil); err != nil {
+		t.Fatalf("verifyPins rejected a hex-encoded pin that matches: %v", err)
+	}
+
+	mismatched := verifyPins([]string{hex.EncodeToString(make([]byte, sha256.Size))})
+	if err := mismatched([][]byte{chain.leafDER}, nil); err == nil {
+		t.Fatalf("verifyPins accepted a certificate that doesn't match any pin")
+	}
+}
+
+// TestBuildTLSClientConfigVerifiesIntermediates is a regression test for
+// the bug fixed in 335b016: the PinnedSPKISHA256 verifier used to check
+// only the leaf against Roots, never adding the rest of rawCerts as
+// Intermediates, so any deployment where the issuing CA wasn't a direct
+// root would fail chain verification even with a correct pin.
+func TestBuildTLSClientConfigVerifiesIntermediates(t *testing.T) {
+	chain := generateTestCertChain(t, "pinned.test")
+	digest := spkiSHA256(chain.leaf)
+
+	cfg := &TLSConfig{
+		ServerName:       "pinned.test",
+		PinnedSPKISHA256: []string{hex.EncodeToString(digest[:])},
+	}
+	tlsCfg, err := buildTLSClientConfig(cfg)
+	if err != nil {
+		t.Fatalf("buildTLSClientConfig failed: %v", err)
+	}
+	tlsCfg.RootCAs = chain.roots
+
+	if err := tlsCfg.VerifyPeerCertificate([][]byte{chain.leafDER, chain.intermediateDER}, nil); err != nil {
+		t.Fatalf("VerifyPeerCertificate rejected a valid leaf+intermediate chain: %v", err)
+	}
+
+	if err := tlsCfg.VerifyPeerCertificate([][]byte{chain.leafDER}, nil); err == nil {
+		t.Fatalf("VerifyPeerCertificate should fail without the intermediate that signed the leaf")
+	}
+}
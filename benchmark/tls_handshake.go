@@ -0,0 +1,47 @@
+package benchmark
+
+import (
+	"time"
+
+	"github.com/teris-io/shortid"
+)
+
+// TlsHandshake measures full TLS handshake latency against s.host,
+// including session resumption when TLSConfig.SessionResumption is set.
+// Unlike TlsConnect, which only times the TCP connect, this subject dials
+// all the way through the TLS handshake and reports "tls:handshake" as a
+// counter distinct from "tls:connect".
+type TlsHandshake struct {
+	WithCounter
+	WithSessions
+	WithTLS
+}
+
+func init() {
+	Register("tls:handshake", func() Subject { return &TlsHandshake{} })
+}
+
+func (s *TlsHandshake) DoConnect() error {
+	id, err := shortid.Generate()
+	if err != nil {
+		return err
+	}
+
+	s.counter.Stat("connection:inprogress", 1)
+
+	start := time.Now()
+	conn, err := s.Dialer().Dial(s.WsScheme()+s.host, nil)
+	if err != nil {
+		s.counter.Stat("connection:inprogress", -1)
+		s.LogError("connection:error", id, "Failed to complete TLS handshake", err)
+		return err
+	}
+	defer conn.Close()
+
+	s.counter.Stat("connection:inprogress", -1)
+	s.counter.Stat("connection:established", 1)
+	s.counter.Stat("tls:handshake", 1)
+	s.counter.Stat("tls:handshake:ms", time.Since(start).Milliseconds())
+
+	return nil
+}